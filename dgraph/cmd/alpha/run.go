@@ -28,6 +28,7 @@ import (
 	_ "net/http/pprof" // http profiler
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -49,7 +50,6 @@ import (
 	"go.opencensus.io/plugin/ocgrpc"
 	otrace "go.opencensus.io/trace"
 	"go.opencensus.io/zpages"
-	"golang.org/x/net/context"
 	"golang.org/x/net/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -168,6 +168,21 @@ they form a Raft group and provide synchronous replication.
 		"Limit for the maximum number of nodes that can be returned in a query that uses the "+
 			"normalize directive.")
 
+	// HTTP server tuning. The timeouts apply to HTTP/2 streams as well, including h2c.
+	flag.Duration("http.read_timeout", 10*time.Second,
+		"Maximum duration for reading an entire HTTP request, including the body."+
+			" Use 0 for no timeout.")
+	flag.Duration("http.write_timeout", 600*time.Second,
+		"Maximum duration before timing out writes of an HTTP response. Use 0 for no timeout.")
+	flag.Duration("http.idle_timeout", 2*time.Minute,
+		"Maximum duration to wait for the next request on a keep-alive HTTP connection.")
+	flag.Uint32("http.max_concurrent_streams", 250,
+		"Maximum number of concurrent HTTP/2 streams allowed per client connection."+
+			" 0 means the default of 250.")
+	flag.Bool("http.h2c", false,
+		"Serve HTTP/2 over cleartext (h2c) to clients with prior knowledge."+
+			" Ignored when TLS is enabled.")
+
 	// TLS configurations
 	flag.String("tls_dir", "", "Path to directory that has TLS certificates and keys.")
 	flag.Bool("tls_use_system_ca", true, "Include System CA into CA Certs.")
@@ -332,30 +347,14 @@ func serveGRPC(l net.Listener, tlsCfg *tls.Config, wg *sync.WaitGroup) {
 	s.Stop()
 }
 
-func serveHTTP(l net.Listener, tlsCfg *tls.Config, wg *sync.WaitGroup) {
+func serveHTTP(l net.Listener, tlsCfg *tls.Config, opts x.HTTPOptions, wg *sync.WaitGroup) {
 	defer wg.Done()
-	srv := &http.Server{
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 600 * time.Second,
-		IdleTimeout:  2 * time.Minute,
-	}
-	var err error
-	switch {
-	case tlsCfg != nil:
-		srv.TLSConfig = tlsCfg
-		err = srv.ServeTLS(l, "", "")
-	default:
-		err = srv.Serve(l)
-	}
-	glog.Errorf("Stopped taking more http(s) requests. Err: %v", err)
-	ctx, cancel := context.WithTimeout(context.Background(), 630*time.Second)
-	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
+	if err := x.ServeHTTP(l, http.DefaultServeMux, tlsCfg, opts); err != nil {
 		log.Printf("Http(s) shutdown err: %v", err.Error())
 	}
 }
 
-func setupServer() {
+func setupServer(httpOpts x.HTTPOptions) {
 	go worker.RunServer(bindall) // For pb.communication.
 
 	laddr := "localhost"
@@ -404,7 +403,7 @@ func setupServer() {
 	var wg sync.WaitGroup
 	wg.Add(3)
 	go serveGRPC(grpcListener, tlsCfg, &wg)
-	go serveHTTP(httpListener, tlsCfg, &wg)
+	go serveHTTP(httpListener, tlsCfg, httpOpts, &wg)
 
 	go func() {
 		defer wg.Done()
@@ -421,6 +420,18 @@ func setupServer() {
 
 var shutdownCh chan struct{}
 
+// httpTimeout parses the duration flag name, exiting if it is invalid or negative.
+func httpTimeout(name string) time.Duration {
+	d, err := time.ParseDuration(Alpha.Conf.GetString(name))
+	if err != nil {
+		glog.Fatalf("Invalid --%s: %v", name, err)
+	}
+	if d < 0 {
+		glog.Fatalf("--%s must not be negative, got %v", name, d)
+	}
+	return d
+}
+
 func run() {
 	bindall = Alpha.Conf.GetBool("bindall")
 
@@ -495,6 +506,22 @@ func run() {
 	x.Config.QueryEdgeLimit = cast.ToUint64(Alpha.Conf.GetString("query_edge_limit"))
 	x.Config.NormalizeNodeLimit = cast.ToInt(Alpha.Conf.GetString("normalize_node_limit"))
 
+	maxStreams, err := strconv.ParseUint(
+		Alpha.Conf.GetString("http.max_concurrent_streams"), 10, 32)
+	if err != nil {
+		glog.Fatalf("Invalid --http.max_concurrent_streams: %v", err)
+	}
+	httpOpts := x.HTTPOptions{
+		ReadTimeout:          httpTimeout("http.read_timeout"),
+		WriteTimeout:         httpTimeout("http.write_timeout"),
+		IdleTimeout:          httpTimeout("http.idle_timeout"),
+		MaxConcurrentStreams: uint32(maxStreams),
+		H2C:                  Alpha.Conf.GetBool("http.h2c"),
+	}
+	if httpOpts.H2C && Alpha.Conf.GetString("tls_dir") != "" {
+		glog.Warningf("--http.h2c is ignored because TLS is enabled via --tls_dir.")
+	}
+
 	x.PrintVersion()
 
 	glog.Infof("x.Config: %+v", x.Config)
@@ -562,7 +589,7 @@ func run() {
 		edgraph.RefreshAcls(aclCloser)
 	}()
 
-	setupServer()
+	setupServer(httpOpts)
 	glog.Infoln("GRPC and HTTP stopped.")
 	aclCloser.SignalAndWait()
 	worker.BlockingStop()
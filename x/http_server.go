@@ -0,0 +1,149 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package x
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+	"golang.org/x/net/http2"
+)
+
+// defaultShutdownTimeout is how long ServeHTTP waits for in-flight requests to finish when
+// the server has no write timeout.
+const defaultShutdownTimeout = 630 * time.Second
+
+// HTTPOptions holds the tuning options for an HTTP server.
+type HTTPOptions struct {
+	// ReadTimeout, WriteTimeout and IdleTimeout are set on the http.Server. The read and
+	// write timeouts apply to each HTTP/2 stream as well, including h2c ones.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// MaxConcurrentStreams is the limit on HTTP/2 streams per connection. Zero means the
+	// default of 250.
+	MaxConcurrentStreams uint32
+	// H2C enables HTTP/2 over cleartext with prior knowledge. It has no effect with TLS.
+	H2C bool
+}
+
+// ServeHTTP serves handler on l until l is closed and then shuts the server down, giving
+// in-flight requests until the write timeout (plus some slack) to finish. HTTP/2 is served
+// over TLS when tlsCfg is not nil, and over cleartext when opts.H2C is set. tlsCfg is not
+// modified.
+func ServeHTTP(l net.Listener, handler http.Handler, tlsCfg *tls.Config, opts HTTPOptions) error {
+	srv := &http.Server{
+		Handler:      handler,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+		IdleTimeout:  opts.IdleTimeout,
+	}
+	h2s := &http2.Server{
+		MaxConcurrentStreams: opts.MaxConcurrentStreams,
+		IdleTimeout:          opts.IdleTimeout,
+	}
+	if tlsCfg != nil {
+		// ConfigureServer modifies the TLS config, which the caller may share with other servers.
+		srv.TLSConfig = tlsCfg.Clone()
+	}
+	if tlsCfg != nil || opts.H2C {
+		// This also registers h2s with srv, so that Shutdown drains HTTP/2 connections.
+		if err := http2.ConfigureServer(srv, h2s); err != nil {
+			return errors.Wrapf(err, "while configuring HTTP/2 server")
+		}
+	}
+
+	var err error
+	switch {
+	case tlsCfg != nil:
+		err = srv.ServeTLS(l, "", "")
+	case opts.H2C:
+		srv.Handler = &h2cHandler{handler: handler, srv: srv, h2s: h2s}
+		err = srv.Serve(l)
+	default:
+		err = srv.Serve(l)
+	}
+	glog.Errorf("Stopped taking more http(s) requests. Err: %v", err)
+
+	timeout := defaultShutdownTimeout
+	if srv.WriteTimeout > 0 {
+		timeout = srv.WriteTimeout + 30*time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
+
+// h2cHandler serves HTTP/2 connections made over cleartext with prior knowledge, and passes
+// every other request to handler. Unlike h2c.NewHandler from x/net, it hands srv to the HTTP/2
+// server as the base config, so that the read and write timeouts apply to h2c streams too.
+type h2cHandler struct {
+	handler http.Handler
+	srv     *http.Server
+	h2s     *http2.Server
+}
+
+func (h *h2cHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PRI" || r.URL.Path != "*" || r.Proto != "HTTP/2.0" || len(r.Header) != 0 {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "h2c is not supported on this connection", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		glog.V(2).Infof("Unable to hijack h2c connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// net/http has consumed "PRI * HTTP/2.0\r\n\r\n"; the rest of the preface must follow.
+	const prefaceRest = "SM\r\n\r\n"
+	buf := make([]byte, len(prefaceRest))
+	if _, err := io.ReadFull(rw, buf); err != nil || string(buf) != prefaceRest {
+		glog.V(2).Infof("Invalid h2c client preface: %q, err: %v", buf, err)
+		return
+	}
+	// The HTTP/2 server expects to read the whole preface, so hand it back in front of whatever
+	// the client has already sent.
+	c := &prefacedConn{
+		Conn: conn,
+		r:    io.MultiReader(strings.NewReader(http2.ClientPreface), rw),
+	}
+	h.h2s.ServeConn(c, &http2.ServeConnOpts{Handler: h.handler, BaseConfig: h.srv})
+}
+
+// prefacedConn is a net.Conn that reads from r instead of the underlying connection.
+type prefacedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *prefacedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2019 Dgraph Labs, Inc. and Contributors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package x
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+// protoHandler replies with the major HTTP version of the request.
+var protoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "%d", r.ProtoMajor)
+})
+
+// startHTTP runs ServeHTTP on a loopback listener. Closing the listener stops the server, and
+// the returned channel receives the result of ServeHTTP.
+func startHTTP(t *testing.T, handler http.Handler, tlsCfg *tls.Config,
+	opts HTTPOptions) (net.Listener, chan error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeHTTP(l, handler, tlsCfg, opts)
+	}()
+	return l, done
+}
+
+// h2cTransport makes HTTP/2 requests over cleartext with prior knowledge.
+func h2cTransport() *http2.Transport {
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}
+}
+
+func getBody(t *testing.T, client *http.Client, url string) string {
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func stopHTTP(t *testing.T, l net.Listener, done chan error) {
+	require.NoError(t, l.Close())
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("ServeHTTP did not return after the listener was closed")
+	}
+}
+
+func TestServeHTTPH2C(t *testing.T) {
+	l, done := startHTTP(t, protoHandler, nil, HTTPOptions{
+		ReadTimeout:  time.Minute,
+		WriteTimeout: time.Minute,
+		H2C:          true,
+	})
+	url := "http://" + l.Addr().String()
+
+	h2 := h2cTransport()
+	require.Equal(t, "2", getBody(t, &http.Client{Transport: h2}, url))
+	h2.CloseIdleConnections()
+
+	// Plain HTTP/1.1 requests are still served by the wrapped handler.
+	h1 := &http.Transport{}
+	require.Equal(t, "1", getBody(t, &http.Client{Transport: h1}, url))
+	h1.CloseIdleConnections()
+
+	stopHTTP(t, l, done)
+}
+
+func TestServeHTTPH2CWriteTimeout(t *testing.T) {
+	slowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+		fmt.Fprint(w, "late")
+	})
+	l, done := startHTTP(t, slowHandler, nil, HTTPOptions{
+		WriteTimeout: 100 * time.Millisecond,
+		H2C:          true,
+	})
+
+	// The stream is reset once the write timeout passes.
+	h2 := h2cTransport()
+	resp, err := (&http.Client{Transport: h2}).Get("http://" + l.Addr().String())
+	if err == nil {
+		_, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	require.Error(t, err)
+	h2.CloseIdleConnections()
+
+	stopHTTP(t, l, done)
+}
+
+func TestServeHTTPTLS(t *testing.T) {
+	tlsCfg := &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}}
+	l, done := startHTTP(t, protoHandler, tlsCfg, HTTPOptions{MaxConcurrentStreams: 10})
+	url := "https://" + l.Addr().String()
+
+	h2 := &http2.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	require.Equal(t, "2", getBody(t, &http.Client{Transport: h2}, url))
+	h2.CloseIdleConnections()
+
+	stopHTTP(t, l, done)
+	// The caller's config may be shared with the gRPC server, so it must be left alone.
+	require.Empty(t, tlsCfg.NextProtos)
+}
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}